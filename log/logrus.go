@@ -1,7 +1,14 @@
 package log
 
 import (
+	"log/syslog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/client9/reopen"
 	"github.com/sirupsen/logrus"
+	lSyslog "github.com/sirupsen/logrus/hooks/syslog"
 	prefixed "github.com/x-cray/logrus-prefixed-formatter"
 )
 
@@ -30,53 +37,68 @@ func NewDefaultLogrusLogger() *LogrusLogger {
 }
 
 func (l *LogrusLogger) Print(args ...interface{}) {
- }
+	l.prepareEntry().Print(args...)
+}
 
 func (l *LogrusLogger) Printf(format string, args ...interface{}) {
- }
+	l.prepareEntry().Printf(format, args...)
+}
 
 func (l *LogrusLogger) Trace(args ...interface{}) {
- }
+	l.prepareEntry().Trace(args...)
+}
 
 func (l *LogrusLogger) Tracef(format string, args ...interface{}) {
- }
+	l.prepareEntry().Tracef(format, args...)
+}
 
 func (l *LogrusLogger) Debug(args ...interface{}) {
- }
+	l.prepareEntry().Debug(args...)
+}
 
 func (l *LogrusLogger) Debugf(format string, args ...interface{}) {
- }
+	l.prepareEntry().Debugf(format, args...)
+}
 
 func (l *LogrusLogger) Info(args ...interface{}) {
- }
+	l.prepareEntry().Info(args...)
+}
 
 func (l *LogrusLogger) Infof(format string, args ...interface{}) {
- }
+	l.prepareEntry().Infof(format, args...)
+}
 
 func (l *LogrusLogger) Warn(args ...interface{}) {
- }
+	l.prepareEntry().Warn(args...)
+}
 
 func (l *LogrusLogger) Warnf(format string, args ...interface{}) {
- }
+	l.prepareEntry().Warnf(format, args...)
+}
 
 func (l *LogrusLogger) Error(args ...interface{}) {
- }
+	l.prepareEntry().Error(args...)
+}
 
 func (l *LogrusLogger) Errorf(format string, args ...interface{}) {
- }
+	l.prepareEntry().Errorf(format, args...)
+}
 
 func (l *LogrusLogger) Fatal(args ...interface{}) {
- }
+	l.prepareEntry().Fatal(args...)
+}
 
 func (l *LogrusLogger) Fatalf(format string, args ...interface{}) {
 	l.prepareEntry().Fatalf(format, args...)
 }
 
 func (l *LogrusLogger) Panic(args ...interface{}) {
- }
+	l.prepareEntry().Panic(args...)
+}
 
 func (l *LogrusLogger) Panicf(format string, args ...interface{}) {
- }
+	l.prepareEntry().Panicf(format, args...)
+}
 
 func (l *LogrusLogger) WithPrefix(prefix string) Logger {
 	return NewLogrusLogger(l.log, prefix, l.Fields())
@@ -99,3 +121,51 @@ func (l *LogrusLogger) prepareEntry() *logrus.Entry {
 		WithFields(logrus.Fields(l.Fields())).
 		WithField("prefix", l.Prefix())
 }
+
+// NewSyslogHookedLogger builds a LogrusLogger that additionally ships every
+// entry to the local or remote syslog daemon over network/addr (pass an
+// empty network to log to the local syslog socket). tag is used as the
+// syslog ident, e.g. the program name.
+func NewSyslogHookedLogger(network, addr string, priority syslog.Priority, tag string) (*LogrusLogger, error) {
+	hook, err := lSyslog.NewSyslogHook(network, addr, priority, tag)
+	if err != nil {
+		return nil, err
+	}
+
+	logger := logrus.New()
+	logger.Formatter = &prefixed.TextFormatter{
+		FullTimestamp:   true,
+		TimestampFormat: "2006-01-02 15:04:05.000",
+	}
+	logger.AddHook(hook)
+
+	return NewLogrusLogger(logger, "main", nil), nil
+}
+
+// NewRotatingFileLogger builds a LogrusLogger that writes to path and
+// reopens the underlying file on SIGHUP, so it cooperates with logrotate
+// (or any other tool that renames the file out from under it) without
+// dropping in-flight log lines.
+func NewRotatingFileLogger(path string) (*LogrusLogger, error) {
+	file, err := reopen.NewFileWriter(path)
+	if err != nil {
+		return nil, err
+	}
+
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+	go func() {
+		for range sighup {
+			file.Reopen()
+		}
+	}()
+
+	logger := logrus.New()
+	logger.Out = file
+	logger.Formatter = &prefixed.TextFormatter{
+		FullTimestamp:   true,
+		TimestampFormat: "2006-01-02 15:04:05.000",
+	}
+
+	return NewLogrusLogger(logger, "main", nil), nil
+}
@@ -0,0 +1,264 @@
+package sip
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/luaxlou/gosip/log"
+)
+
+// Dialog is the subset of RFC 3261 §12 dialog state needed to build further
+// in-dialog requests (BYE, REFER, ...) without callers hand-rolling header
+// copying and branch/tag/CSeq bookkeeping for every workflow. Callers
+// populate it once a dialog is established and keep it around for the
+// lifetime of the call.
+type Dialog struct {
+	CallID       string
+	LocalURI     Uri
+	LocalTag     string
+	RemoteURI    Uri
+	RemoteTag    string
+	RemoteTarget Uri
+	RouteSet     []Uri
+	LocalSeqNo   uint32
+	SipVersion   string
+	// LocalAddr is this side's "host:port", used as the sent-by of the
+	// Via header every further in-dialog request needs (RFC 3261 §8.1.1).
+	LocalAddr string
+	// ViaTransport is the Via transport, e.g. "UDP", "TCP" or "TLS". Named
+	// to avoid colliding with the package's own *Transport type (trusted
+	// proxies + MessageLogger config), which is unrelated.
+	ViaTransport string
+}
+
+func (dialog *Dialog) fromHeader() *FromHeader {
+	params := NewParams()
+	params.Add("tag", String{Str: dialog.LocalTag})
+
+	return &FromHeader{
+		Address: dialog.LocalURI,
+		Params:  params,
+	}
+}
+
+func (dialog *Dialog) toHeader() *ToHeader {
+	params := NewParams()
+	params.Add("tag", String{Str: dialog.RemoteTag})
+
+	return &ToHeader{
+		Address: dialog.RemoteURI,
+		Params:  params,
+	}
+}
+
+// viaHeader builds a fresh Via header for a new in-dialog request, with its
+// own branch, sent-by taken from the dialog's local contact/transport.
+func (dialog *Dialog) viaHeader() ViaHeader {
+	host, port := splitHostPort(dialog.LocalAddr)
+
+	params := NewParams()
+	params.Add("branch", String{Str: GenerateBranch()})
+
+	return ViaHeader{
+		&ViaHop{
+			ProtocolName:    "SIP",
+			ProtocolVersion: "2.0",
+			Transport:       dialog.ViaTransport,
+			Host:            host,
+			Port:            port,
+			Params:          params,
+		},
+	}
+}
+
+func splitHostPort(addr string) (string, *Port) {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr, nil
+	}
+
+	p, err := strconv.Atoi(portStr)
+	if err != nil {
+		return host, nil
+	}
+
+	port := Port(uint16(p))
+
+	return host, &port
+}
+
+func (dialog *Dialog) routeHeader() (*RouteHeader, bool) {
+	if len(dialog.RouteSet) == 0 {
+		return nil, false
+	}
+
+	addresses := make([]Uri, 0, len(dialog.RouteSet))
+	for _, uri := range dialog.RouteSet {
+		addresses = append(addresses, uri.Clone())
+	}
+
+	return &RouteHeader{Addresses: addresses}, true
+}
+
+// NewByeRequestFromDialog builds an in-dialog BYE from stored Dialog state,
+// incrementing the dialog's local CSeq.
+func NewByeRequestFromDialog(byeID MessageID, dialog *Dialog, fields log.Fields, transport ...*Transport) Request {
+	dialog.LocalSeqNo++
+
+	byeRequest := newRequest(
+		byeID,
+		BYE,
+		dialog.RemoteTarget.Clone(),
+		dialog.SipVersion,
+		[]Header{},
+		"",
+		fields.WithFields(log.Fields{
+			"call_id": dialog.CallID,
+		}),
+		transport...,
+	)
+
+	byeRequest.AppendHeader(dialog.viaHeader())
+	byeRequest.AppendHeader(CallID(dialog.CallID))
+	byeRequest.AppendHeader(dialog.fromHeader())
+	byeRequest.AppendHeader(dialog.toHeader())
+	if route, ok := dialog.routeHeader(); ok {
+		byeRequest.AppendHeader(route)
+	}
+	byeRequest.AppendHeader(&CSeq{SeqNo: dialog.LocalSeqNo, MethodName: BYE})
+
+	resolveTransport(transport).logMessage(byeRequest, Outbound)
+
+	return byeRequest
+}
+
+// NewReferRequest builds an in-dialog REFER asking the dialog's remote
+// party to contact referTo, optionally replacing an existing dialog per
+// RFC 3891 (attended transfer) when replaces is non-nil.
+func NewReferRequest(referID MessageID, dialog *Dialog, referTo Uri, replaces *ReplacesHeader, fields log.Fields, transport ...*Transport) Request {
+	dialog.LocalSeqNo++
+
+	referRequest := newRequest(
+		referID,
+		REFER,
+		dialog.RemoteTarget.Clone(),
+		dialog.SipVersion,
+		[]Header{},
+		"",
+		fields.WithFields(log.Fields{
+			"call_id": dialog.CallID,
+		}),
+		transport...,
+	)
+
+	referRequest.AppendHeader(dialog.viaHeader())
+	referRequest.AppendHeader(CallID(dialog.CallID))
+	referRequest.AppendHeader(dialog.fromHeader())
+	referRequest.AppendHeader(dialog.toHeader())
+	if route, ok := dialog.routeHeader(); ok {
+		referRequest.AppendHeader(route)
+	}
+	referRequest.AppendHeader(&CSeq{SeqNo: dialog.LocalSeqNo, MethodName: REFER})
+
+	referToHeader := &ReferToHeader{Address: referTo}
+	if replaces != nil {
+		referToHeader.Address = referTo.Clone()
+		referToHeader.Replaces = replaces
+	}
+	referRequest.AppendHeader(referToHeader)
+
+	resolveTransport(transport).logMessage(referRequest, Outbound)
+
+	return referRequest
+}
+
+// RAckHeader is the RAck header defined by RFC 3262, correlating a PRACK
+// with the reliable provisional response it acknowledges.
+type RAckHeader struct {
+	RSeq       uint32
+	CSeq       uint32
+	MethodName RequestMethod
+}
+
+func (rack *RAckHeader) Name() string { return "RAck" }
+
+func (rack *RAckHeader) String() string {
+	return fmt.Sprintf("RAck: %d %d %s", rack.RSeq, rack.CSeq, rack.MethodName)
+}
+
+func (rack *RAckHeader) Clone() Header {
+	clone := *rack
+	return &clone
+}
+
+// RSeqHeader is the RSeq header defined by RFC 3262, sequencing a UAS's
+// reliable provisional responses within a transaction.
+type RSeqHeader uint32
+
+func (rseq RSeqHeader) Name() string { return "RSeq" }
+
+func (rseq RSeqHeader) String() string {
+	return fmt.Sprintf("RSeq: %d", uint32(rseq))
+}
+
+func (rseq RSeqHeader) Clone() Header { return rseq }
+
+// ReplacesHeader is the Replaces header defined by RFC 3891, used to
+// atomically replace an existing dialog, e.g. for attended call transfer
+// via REFER.
+type ReplacesHeader struct {
+	CallID    string
+	ToTag     string
+	FromTag   string
+	EarlyOnly bool
+}
+
+func (replaces *ReplacesHeader) Name() string { return "Replaces" }
+
+func (replaces *ReplacesHeader) String() string {
+	s := fmt.Sprintf("Replaces: %s;to-tag=%s;from-tag=%s", replaces.CallID, replaces.ToTag, replaces.FromTag)
+	if replaces.EarlyOnly {
+		s += ";early-only"
+	}
+
+	return s
+}
+
+func (replaces *ReplacesHeader) Clone() Header {
+	clone := *replaces
+	return &clone
+}
+
+// ReferToHeader is the Refer-To header defined by RFC 3515, carrying the
+// target URI a REFER asks the recipient to contact, with an optional
+// embedded Replaces for attended transfer.
+type ReferToHeader struct {
+	Address  Uri
+	Replaces *ReplacesHeader
+}
+
+func (referTo *ReferToHeader) Name() string { return "Refer-To" }
+
+func (referTo *ReferToHeader) String() string {
+	if referTo.Replaces == nil {
+		return fmt.Sprintf("Refer-To: <%s>", referTo.Address)
+	}
+
+	return fmt.Sprintf(
+		"Refer-To: <%s?Replaces=%s%%3Bto-tag%%3D%s%%3Bfrom-tag%%3D%s>",
+		referTo.Address,
+		referTo.Replaces.CallID,
+		referTo.Replaces.ToTag,
+		referTo.Replaces.FromTag,
+	)
+}
+
+func (referTo *ReferToHeader) Clone() Header {
+	clone := &ReferToHeader{Address: referTo.Address.Clone()}
+	if referTo.Replaces != nil {
+		clone.Replaces = referTo.Replaces.Clone().(*ReplacesHeader)
+	}
+
+	return clone
+}
@@ -0,0 +1,98 @@
+package sip
+
+import (
+	"testing"
+
+	"github.com/luaxlou/gosip/log"
+)
+
+func testDialog(callID string) *Dialog {
+	return &Dialog{
+		CallID:       callID,
+		LocalURI:     &SipUri{FHost: "alice.example.com"},
+		LocalTag:     "localtag",
+		RemoteURI:    &SipUri{FHost: "bob.example.com"},
+		RemoteTag:    "remotetag",
+		RemoteTarget: &SipUri{FHost: "bob.example.com"},
+		LocalSeqNo:   1,
+		SipVersion:   "SIP/2.0",
+		LocalAddr:    "192.0.2.1:5060",
+		ViaTransport: "UDP",
+	}
+}
+
+func TestNewByeRequestFromDialogSetsDialogHeaders(t *testing.T) {
+	dialog := testDialog("call-1")
+
+	bye := NewByeRequestFromDialog("", dialog, log.Fields{})
+
+	if bye.Method() != BYE {
+		t.Fatalf("Method() = %v, want BYE", bye.Method())
+	}
+	if _, ok := bye.ViaHop(); !ok {
+		t.Fatal("expected BYE to carry a Via header")
+	}
+	if len(bye.GetHeaders("Call-ID")) != 1 {
+		t.Fatal("expected exactly one Call-ID header")
+	}
+	if len(bye.GetHeaders("From")) != 1 || len(bye.GetHeaders("To")) != 1 {
+		t.Fatal("expected From and To headers")
+	}
+
+	cseq, ok := bye.CSeq()
+	if !ok {
+		t.Fatal("expected a CSeq header")
+	}
+	if cseq.SeqNo != 2 || cseq.MethodName != BYE {
+		t.Fatalf("CSeq = %+v, want {SeqNo:2 MethodName:BYE}", cseq)
+	}
+	if dialog.LocalSeqNo != 2 {
+		t.Fatalf("dialog.LocalSeqNo = %d, want 2", dialog.LocalSeqNo)
+	}
+}
+
+func TestNewReferRequestCarriesReplaces(t *testing.T) {
+	dialog := testDialog("call-2")
+	replaces := &ReplacesHeader{CallID: "other-call", ToTag: "totag", FromTag: "fromtag"}
+
+	refer := NewReferRequest("", dialog, &SipUri{FHost: "carol.example.com"}, replaces, log.Fields{})
+
+	if refer.Method() != REFER {
+		t.Fatalf("Method() = %v, want REFER", refer.Method())
+	}
+	if _, ok := refer.ViaHop(); !ok {
+		t.Fatal("expected REFER to carry a Via header")
+	}
+
+	hdrs := refer.GetHeaders("Refer-To")
+	if len(hdrs) != 1 {
+		t.Fatalf("len(Refer-To headers) = %d, want 1", len(hdrs))
+	}
+	referTo, ok := hdrs[0].(*ReferToHeader)
+	if !ok {
+		t.Fatalf("Refer-To header has type %T, want *ReferToHeader", hdrs[0])
+	}
+	if referTo.Replaces != replaces {
+		t.Fatal("expected Refer-To to carry the given Replaces")
+	}
+
+	cseq, ok := refer.CSeq()
+	if !ok || cseq.SeqNo != 2 || cseq.MethodName != REFER {
+		t.Fatalf("CSeq = %+v, ok=%v, want {SeqNo:2 MethodName:REFER}", cseq, ok)
+	}
+}
+
+func TestNewReferRequestWithoutReplaces(t *testing.T) {
+	dialog := testDialog("call-3")
+
+	refer := NewReferRequest("", dialog, &SipUri{FHost: "carol.example.com"}, nil, log.Fields{})
+
+	hdrs := refer.GetHeaders("Refer-To")
+	if len(hdrs) != 1 {
+		t.Fatalf("len(Refer-To headers) = %d, want 1", len(hdrs))
+	}
+	referTo := hdrs[0].(*ReferToHeader)
+	if referTo.Replaces != nil {
+		t.Fatal("expected no Replaces when none was given")
+	}
+}
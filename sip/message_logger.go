@@ -0,0 +1,234 @@
+package sip
+
+import (
+	"container/list"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MessageLogger records every locally-generated Request/Response for
+// CDR/audit purposes, independent of the structured log.Fields attached to
+// messages elsewhere in the package. Implementations must be safe for
+// concurrent use.
+type MessageLogger interface {
+	// Append renders msg, tagged with the direction it crossed the wire
+	// in, and writes it to the log for the dialog it belongs to, opening
+	// the underlying log on first use.
+	Append(msg Message, dir Direction) error
+	// Close releases any resources (open files) held by the logger.
+	Close() error
+}
+
+// Direction marks which way a logged message crossed the wire.
+type Direction string
+
+const (
+	Outbound Direction = "out"
+	Inbound  Direction = "in"
+)
+
+const defaultMaxOpenMessageLogs = 256
+
+// MessageLoggerOption configures a FileMessageLogger.
+type MessageLoggerOption func(*fileMessageLogger)
+
+// WithMaxOpenMessageLogs bounds how many dialog log files a
+// FileMessageLogger keeps open at once; the least-recently-used one is
+// closed (and transparently reopened on the next Append) once the limit is
+// exceeded.
+func WithMaxOpenMessageLogs(n int) MessageLoggerOption {
+	return func(l *fileMessageLogger) {
+		l.maxOpenFiles = n
+	}
+}
+
+type openLogFile struct {
+	key  string
+	file *os.File
+}
+
+// fileMessageLogger is a MessageLogger that appends a human-readable
+// rendering of every message to root/<yyyy-mm-dd>/<dialog-key>.log, keeping
+// at most maxOpenFiles file descriptors open across all active dialogs.
+// The file name is the dialog key with its "|" separators written as "_".
+// dialog-key folds in the From/To tags alongside the Call-ID (see
+// dialogKey), so forked early dialogs that share a Call-ID get distinct
+// files and distinct LRU slots instead of contending for one descriptor.
+type fileMessageLogger struct {
+	root         string
+	maxOpenFiles int
+
+	mu    sync.Mutex
+	lru   *list.List
+	files map[string]*list.Element
+}
+
+// NewFileMessageLogger builds a MessageLogger that lazily opens
+// root/<yyyy-mm-dd>/<dialog-key>.log and appends every message it is given.
+func NewFileMessageLogger(root string, opts ...MessageLoggerOption) MessageLogger {
+	l := &fileMessageLogger{
+		root:         root,
+		maxOpenFiles: defaultMaxOpenMessageLogs,
+		lru:          list.New(),
+		files:        make(map[string]*list.Element),
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+
+	return l
+}
+
+func (l *fileMessageLogger) Append(msg Message, dir Direction) error {
+	callID, ok := msg.CallID()
+	if !ok {
+		return fmt.Errorf("sip: message logger: %s has no Call-ID", msg.Short())
+	}
+
+	now := time.Now()
+	date := now.Format("2006-01-02")
+	logDir := filepath.Join(l.root, date)
+	key := dialogKey(msg, callID.String())
+	path := filepath.Join(logDir, fmt.Sprintf("%s.log", strings.ReplaceAll(key, "|", "_")))
+
+	f, err := l.open(date+"|"+key, logDir, path)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(
+		f,
+		"%s [dir=%s] %s\n%s\n\n%s\n---\n",
+		now.Format(time.RFC3339Nano),
+		dir,
+		msg.StartLine(),
+		renderHeaders(msg),
+		msg.Body(),
+	)
+
+	return err
+}
+
+// open returns the file for key, reusing an already-open descriptor when
+// one exists. key folds in the current date, so a dialog that's still
+// open when the day rolls over reopens (and rotates into) the new day's
+// file instead of keeping the previous day's descriptor forever.
+func (l *fileMessageLogger) open(key, dir, path string) (*os.File, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if el, ok := l.files[key]; ok {
+		l.lru.MoveToFront(el)
+		return el.Value.(*openLogFile).file, nil
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	el := l.lru.PushFront(&openLogFile{key: key, file: f})
+	l.files[key] = el
+
+	for l.lru.Len() > l.maxOpenFiles {
+		oldest := l.lru.Back()
+		of := l.lru.Remove(oldest).(*openLogFile)
+		delete(l.files, of.key)
+		of.file.Close()
+	}
+
+	return f, nil
+}
+
+func (l *fileMessageLogger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var firstErr error
+	for el := l.lru.Front(); el != nil; el = el.Next() {
+		if err := el.Value.(*openLogFile).file.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	l.lru.Init()
+	l.files = make(map[string]*list.Element)
+
+	return firstErr
+}
+
+// dialogKey disambiguates early, forked dialogs that still share a Call-ID
+// but already carry distinct From/To tags.
+func dialogKey(msg Message, callID string) string {
+	key := callID
+	if from, ok := msg.From(); ok {
+		if tag, ok := from.Params.Get("tag"); ok && tag != nil {
+			key += "|" + tag.String()
+		}
+	}
+	if to, ok := msg.To(); ok {
+		if tag, ok := to.Params.Get("tag"); ok && tag != nil {
+			key += "|" + tag.String()
+		}
+	}
+
+	return key
+}
+
+func renderHeaders(msg Message) string {
+	var b strings.Builder
+	for _, h := range msg.Headers() {
+		b.WriteString(h.String())
+		b.WriteString("\r\n")
+	}
+
+	return b.String()
+}
+
+// InMemoryMessageLogger is a MessageLogger that keeps every appended
+// message's rendering in memory, for use in tests.
+type InMemoryMessageLogger struct {
+	mu      sync.Mutex
+	entries []string
+	closed  bool
+}
+
+func NewInMemoryMessageLogger() *InMemoryMessageLogger {
+	return &InMemoryMessageLogger{}
+}
+
+func (l *InMemoryMessageLogger) Append(msg Message, dir Direction) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.entries = append(l.entries, fmt.Sprintf("[dir=%s] %s", dir, msg.String()))
+
+	return nil
+}
+
+func (l *InMemoryMessageLogger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.closed = true
+
+	return nil
+}
+
+// Entries returns a snapshot of every message appended so far.
+func (l *InMemoryMessageLogger) Entries() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	out := make([]string, len(l.entries))
+	copy(out, l.entries)
+
+	return out
+}
+
@@ -0,0 +1,94 @@
+package sip
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/luaxlou/gosip/log"
+)
+
+func TestInMemoryMessageLoggerRecordsDirection(t *testing.T) {
+	logger := NewInMemoryMessageLogger()
+
+	bye := NewByeRequestFromDialog("", testDialog("call-1"), log.Fields{})
+	if err := logger.Append(bye, Outbound); err != nil {
+		t.Fatal(err)
+	}
+
+	entries := logger.Entries()
+	if len(entries) != 1 {
+		t.Fatalf("len(entries) = %d, want 1", len(entries))
+	}
+	if !strings.HasPrefix(entries[0], "[dir=out] ") {
+		t.Fatalf("entries[0] = %q, want it to start with \"[dir=out] \"", entries[0])
+	}
+}
+
+func TestFileMessageLoggerEvictsLeastRecentlyUsedFile(t *testing.T) {
+	root := t.TempDir()
+	logger := NewFileMessageLogger(root, WithMaxOpenMessageLogs(1)).(*fileMessageLogger)
+	defer logger.Close()
+
+	first := NewByeRequestFromDialog("", testDialog("call-1"), log.Fields{})
+	second := NewByeRequestFromDialog("", testDialog("call-2"), log.Fields{})
+
+	if err := logger.Append(first, Outbound); err != nil {
+		t.Fatal(err)
+	}
+	if err := logger.Append(second, Outbound); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := logger.lru.Len(), 1; got != want {
+		t.Fatalf("open files = %d, want %d (call-1's descriptor should have been evicted)", got, want)
+	}
+
+	// Appending to the evicted dialog again must reopen its file and
+	// append to it, not overwrite what was already flushed to disk.
+	if err := logger.Append(first, Outbound); err != nil {
+		t.Fatal(err)
+	}
+
+	date := time.Now().Format("2006-01-02")
+	path := filepath.Join(root, date, "call-1_localtag_remotetag.log")
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Count(string(contents), "[dir=out] BYE "); got != 2 {
+		t.Fatalf("call-1_localtag_remotetag.log has %d BYE entries, want 2", got)
+	}
+}
+
+func TestFileMessageLoggerSeparatesForkedDialogsSharingACallID(t *testing.T) {
+	root := t.TempDir()
+	logger := NewFileMessageLogger(root, WithMaxOpenMessageLogs(2)).(*fileMessageLogger)
+	defer logger.Close()
+
+	forkA := testDialog("forked-call")
+	forkA.RemoteTag = "branch-a"
+	forkB := testDialog("forked-call")
+	forkB.RemoteTag = "branch-b"
+
+	if err := logger.Append(NewByeRequestFromDialog("", forkA, log.Fields{}), Outbound); err != nil {
+		t.Fatal(err)
+	}
+	if err := logger.Append(NewByeRequestFromDialog("", forkB, log.Fields{}), Outbound); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := logger.lru.Len(), 2; got != want {
+		t.Fatalf("open files = %d, want %d (forked dialogs must not share a descriptor)", got, want)
+	}
+
+	date := time.Now().Format("2006-01-02")
+	if _, err := os.Stat(filepath.Join(root, date, "forked-call_localtag_branch-a.log")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(root, date, "forked-call_localtag_branch-b.log")); err != nil {
+		t.Fatal(err)
+	}
+}
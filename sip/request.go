@@ -24,6 +24,10 @@ type request struct {
 	message
 	method    RequestMethod
 	recipient Uri
+	// transport is the Transport this request was built through, if any;
+	// it backs Source()'s trusted-proxy resolution and is carried forward
+	// by Clone()/WithFields() so derived requests keep the same policy.
+	transport *Transport
 }
 
 func NewRequest(
@@ -34,6 +38,26 @@ func NewRequest(
 	hdrs []Header,
 	body string,
 	fields log.Fields,
+	transport ...*Transport,
+) Request {
+	req := newRequest(messID, method, recipient, sipVersion, hdrs, body, fields, transport...)
+	resolveTransport(transport).logMessage(req, Outbound)
+
+	return req
+}
+
+// newRequest builds a request without appending it to the configured
+// MessageLogger, so callers that still need to mutate the result (e.g.
+// NewAckRequest, NewCancelRequest) don't log an incomplete message.
+func newRequest(
+	messID MessageID,
+	method RequestMethod,
+	recipient Uri,
+	sipVersion string,
+	hdrs []Header,
+	body string,
+	fields log.Fields,
+	transport ...*Transport,
 ) Request {
 	req := new(request)
 	if messID == "" {
@@ -49,6 +73,7 @@ func NewRequest(
 	req.fields = fields.WithFields(log.Fields{
 		"request_id": req.messID,
 	})
+	req.transport = resolveTransport(transport)
 
 	if strings.TrimSpace(body) != "" {
 		req.SetBody(body, true)
@@ -105,8 +130,12 @@ func (req *request) StartLine() string {
 	return buffer.String()
 }
 
+// Clone builds an independent copy of req. This is an in-memory operation
+// on a message that was already logged (if at all) when it was first built
+// or received, so it uses newRequest directly rather than NewRequest to
+// avoid appending a duplicate entry to the configured MessageLogger.
 func (req *request) Clone() Message {
-	return NewRequest(
+	return newRequest(
 		"",
 		req.Method(),
 		req.Recipient().Clone(),
@@ -114,11 +143,15 @@ func (req *request) Clone() Message {
 		req.headers.CloneHeaders(),
 		req.Body(),
 		req.Fields(),
+		req.transport,
 	)
 }
 
+// WithFields returns a copy of req carrying additional log.Fields. Like
+// Clone, this re-tags an already-logged message rather than sending a new
+// one, so it uses newRequest directly to avoid double-logging it.
 func (req *request) WithFields(fields log.Fields) Message {
-	return NewRequest(
+	return newRequest(
 		req.MessageID(),
 		req.Method(),
 		req.Recipient().Clone(),
@@ -126,6 +159,7 @@ func (req *request) WithFields(fields log.Fields) Message {
 		req.headers.CloneHeaders(),
 		req.Body(),
 		req.Fields().WithFields(fields),
+		req.transport,
 	)
 }
 
@@ -141,32 +175,47 @@ func (req *request) IsCancel() bool {
 	return req.Method() == CANCEL
 }
 
+// Source resolves the address of the UA that actually sent this request.
+// When the message only crossed untrusted hops, that's simply the top Via
+// hop, as before. When this request's Transport has trusted proxies
+// configured via Transport.SetTrustedProxies (a front-end Kamailio/OpenSIPS
+// or edge SBC), Source walks down the Via stack and returns the first hop
+// past them.
 func (req *request) Source() string {
 	if req.src != "" {
 		return req.src
 	}
 
-	viaHop, ok := req.ViaHop()
-	if !ok {
+	hops := req.viaHops()
+	if len(hops) == 0 {
 		return ""
 	}
 
+	proxies := req.transport.TrustedProxies()
+	hop := hops[0]
+	for _, h := range hops {
+		hop = h
+		if !isTrustedProxyHost(viaHopHost(h), proxies) {
+			break
+		}
+	}
+
 	var (
 		host string
 		port Port
 	)
 
-	if received, ok := viaHop.Params.Get("received"); ok && received.String() != "" {
+	if received, ok := hop.Params.Get("received"); ok && received.String() != "" {
 		host = received.String()
 	} else {
-		host = viaHop.Host
+		host = hop.Host
 	}
 
-	if rport, ok := viaHop.Params.Get("rport"); ok && rport != nil && rport.String() != "" {
+	if rport, ok := hop.Params.Get("rport"); ok && rport != nil && rport.String() != "" {
 		p, _ := strconv.Atoi(rport.String())
 		port = Port(uint16(p))
-	} else if viaHop.Port != nil {
-		port = *viaHop.Port
+	} else if hop.Port != nil {
+		port = *hop.Port
 	} else {
 		port = DefaultPort(req.Transport())
 	}
@@ -174,6 +223,27 @@ func (req *request) Source() string {
 	return fmt.Sprintf("%v:%v", host, port)
 }
 
+// viaHops flattens every Via header on the request, in order, into a
+// single top-to-bottom list of hops.
+func (req *request) viaHops() []*ViaHop {
+	var hops []*ViaHop
+	for _, h := range req.GetHeaders("Via") {
+		if via, ok := h.(ViaHeader); ok {
+			hops = append(hops, via...)
+		}
+	}
+
+	return hops
+}
+
+func viaHopHost(hop *ViaHop) string {
+	if received, ok := hop.Params.Get("received"); ok && received.String() != "" {
+		return received.String()
+	}
+
+	return hop.Host
+}
+
 func (req *request) Destination() string {
 	if req.dest != "" {
 		return req.dest
@@ -207,12 +277,12 @@ func (req *request) Destination() string {
 
 // NewAckForInvite creates ACK request for 2xx INVITE
 // https://tools.ietf.org/html/rfc3261#section-13.2.2.4
-func NewAckRequest(ackID MessageID, inviteRequest Request, inviteResponse Response, fields log.Fields) Request {
+func NewAckRequest(ackID MessageID, inviteRequest Request, inviteResponse Response, fields log.Fields, transport ...*Transport) Request {
 	recipient := inviteRequest.Recipient()
 	if contact, ok := inviteResponse.Contact(); ok {
 		recipient = contact.Address
 	}
-	ackRequest := NewRequest(
+	ackRequest := newRequest(
 		ackID,
 		ACK,
 		recipient,
@@ -225,6 +295,7 @@ func NewAckRequest(ackID MessageID, inviteRequest Request, inviteResponse Respon
 				"invite_request_id":  inviteRequest.MessageID(),
 				"invite_response_id": inviteResponse.MessageID(),
 			}),
+		transport...,
 	)
 
 	CopyHeaders("Via", inviteRequest, ackRequest)
@@ -253,11 +324,13 @@ func NewAckRequest(ackID MessageID, inviteRequest Request, inviteResponse Respon
 	cseq, _ := ackRequest.CSeq()
 	cseq.MethodName = ACK
 
+	resolveTransport(transport).logMessage(ackRequest, Outbound)
+
 	return ackRequest
 }
 
-func NewCancelRequest(cancelID MessageID, requestForCancel Request, fields log.Fields) Request {
-	cancelReq := NewRequest(
+func NewCancelRequest(cancelID MessageID, requestForCancel Request, fields log.Fields, transport ...*Transport) Request {
+	cancelReq := newRequest(
 		cancelID,
 		CANCEL,
 		requestForCancel.Recipient(),
@@ -269,6 +342,7 @@ func NewCancelRequest(cancelID MessageID, requestForCancel Request, fields log.F
 			WithFields(log.Fields{
 				"cancelling_request_id": requestForCancel.MessageID(),
 			}),
+		transport...,
 	)
 
 	viaHop, _ := requestForCancel.ViaHop()
@@ -281,16 +355,118 @@ func NewCancelRequest(cancelID MessageID, requestForCancel Request, fields log.F
 	cseq, _ := cancelReq.CSeq()
 	cseq.MethodName = CANCEL
 
+	resolveTransport(transport).logMessage(cancelReq, Outbound)
+
 	return cancelReq
 }
 
+// NewNon2xxAckRequest creates the ACK for a non-2xx final response to an
+// INVITE - https://tools.ietf.org/html/rfc3261#section-17.1.1.3. Unlike the
+// 2xx ACK, this one belongs to the INVITE transaction itself: it reuses the
+// INVITE's Via (same branch) and Route set outright, instead of generating
+// a new branch and recomputing the route set from the response's
+// Record-Route headers.
+func NewNon2xxAckRequest(ackID MessageID, inviteRequest Request, inviteResponse Response, fields log.Fields, transport ...*Transport) Request {
+	ackRequest := newRequest(
+		ackID,
+		ACK,
+		inviteRequest.Recipient(),
+		inviteResponse.SipVersion(),
+		[]Header{},
+		"",
+		inviteRequest.Fields().
+			WithFields(fields).
+			WithFields(log.Fields{
+				"invite_request_id":  inviteRequest.MessageID(),
+				"invite_response_id": inviteResponse.MessageID(),
+			}),
+		transport...,
+	)
+
+	CopyHeaders("Via", inviteRequest, ackRequest)
+	CopyHeaders("Route", inviteRequest, ackRequest)
+	CopyHeaders("From", inviteRequest, ackRequest)
+	CopyHeaders("To", inviteResponse, ackRequest)
+	CopyHeaders("Call-ID", inviteRequest, ackRequest)
+	CopyHeaders("CSeq", inviteRequest, ackRequest)
+	cseq, _ := ackRequest.CSeq()
+	cseq.MethodName = ACK
+
+	resolveTransport(transport).logMessage(ackRequest, Outbound)
+
+	return ackRequest
+}
+
+// NewPrackRequest creates a PRACK acknowledging a reliable provisional
+// response - https://tools.ietf.org/html/rfc3262. The RAck header
+// correlates it to that response via the response's RSeq and the CSeq of
+// the request it answers. Like NewByeRequestFromDialog and
+// NewReferRequest, it takes the dialog's stored state so the PRACK gets
+// the dialog's own next local CSeq (not one derived from the response
+// being acknowledged, which would collide across multiple reliable
+// provisional responses to the same INVITE) and the dialog's route set,
+// rather than falling back to whatever Contact the response happens to
+// carry.
+func NewPrackRequest(prackID MessageID, dialog *Dialog, provisionalResponse Response, fields log.Fields, transport ...*Transport) Request {
+	dialog.LocalSeqNo++
+
+	ackedCSeq, _ := provisionalResponse.CSeq()
+
+	var rseq RSeqHeader
+	if hdrs := provisionalResponse.GetHeaders("RSeq"); len(hdrs) > 0 {
+		rseq = hdrs[0].(RSeqHeader)
+	}
+
+	prackRequest := newRequest(
+		prackID,
+		PRACK,
+		dialog.RemoteTarget.Clone(),
+		dialog.SipVersion,
+		[]Header{},
+		"",
+		provisionalResponse.Fields().
+			WithFields(fields).
+			WithFields(log.Fields{
+				"provisional_response_id": provisionalResponse.MessageID(),
+			}),
+		transport...,
+	)
+
+	prackRequest.AppendHeader(dialog.viaHeader())
+	prackRequest.AppendHeader(CallID(dialog.CallID))
+	prackRequest.AppendHeader(dialog.fromHeader())
+	prackRequest.AppendHeader(dialog.toHeader())
+	if route, ok := dialog.routeHeader(); ok {
+		prackRequest.AppendHeader(route)
+	}
+	prackRequest.AppendHeader(&CSeq{SeqNo: dialog.LocalSeqNo, MethodName: PRACK})
+
+	prackRequest.AppendHeader(&RAckHeader{
+		RSeq:       uint32(rseq),
+		CSeq:       ackedCSeq.SeqNo,
+		MethodName: ackedCSeq.MethodName,
+	})
+
+	resolveTransport(transport).logMessage(prackRequest, Outbound)
+
+	return prackRequest
+}
+
+// CopyRequest builds an independent copy of req, headers included. Like
+// Clone, this duplicates an already-built message rather than sending a new
+// one, so it uses newRequest directly to avoid double-logging it.
 func CopyRequest(req Request) Request {
 	hdrs := make([]Header, 0)
 	for _, header := range req.Headers() {
 		hdrs = append(hdrs, header.Clone())
 	}
 
-	return NewRequest(
+	var transport *Transport
+	if r, ok := req.(*request); ok {
+		transport = r.transport
+	}
+
+	return newRequest(
 		req.MessageID(),
 		req.Method(),
 		req.Recipient().Clone(),
@@ -298,5 +474,6 @@ func CopyRequest(req Request) Request {
 		hdrs,
 		req.Body(),
 		req.Fields(),
+		transport,
 	)
 }
@@ -0,0 +1,225 @@
+package sip
+
+import (
+	"net"
+	"testing"
+
+	"github.com/luaxlou/gosip/log"
+)
+
+// fakeResponse is a minimal Response fixture for exercising the request
+// builders that take one (NewPrackRequest, NewAckRequest,
+// NewNon2xxAckRequest). It delegates every Message method to a real request
+// built through newRequest, and only stubs the handful of
+// response-specific methods those builders don't touch.
+type fakeResponse struct {
+	Message
+	statusCode int
+	reason     string
+}
+
+func newFakeResponse(statusCode int, reason string, hdrs []Header, fields log.Fields) *fakeResponse {
+	msg := newRequest("", INVITE, &SipUri{FHost: "example.com"}, "SIP/2.0", hdrs, "", fields)
+
+	return &fakeResponse{Message: msg, statusCode: statusCode, reason: reason}
+}
+
+func (r *fakeResponse) StatusCode() StatusCode     { return StatusCode(r.statusCode) }
+func (r *fakeResponse) SetStatusCode(c StatusCode) { r.statusCode = int(c) }
+func (r *fakeResponse) Reason() string             { return r.reason }
+func (r *fakeResponse) SetReason(reason string)    { r.reason = reason }
+func (r *fakeResponse) IsProvisional() bool        { return r.statusCode/100 == 1 }
+func (r *fakeResponse) IsSuccess() bool            { return r.statusCode/100 == 2 }
+func (r *fakeResponse) IsRedirection() bool        { return r.statusCode/100 == 3 }
+func (r *fakeResponse) IsClientError() bool        { return r.statusCode/100 == 4 }
+func (r *fakeResponse) IsServerError() bool        { return r.statusCode/100 == 5 }
+func (r *fakeResponse) IsGlobalError() bool        { return r.statusCode/100 == 6 }
+
+func testViaHop(host string, port uint16, received string) *ViaHop {
+	params := NewParams()
+	if received != "" {
+		params.Add("received", String{Str: received})
+	}
+	p := Port(port)
+
+	return &ViaHop{
+		ProtocolName:    "SIP",
+		ProtocolVersion: "2.0",
+		Transport:       "UDP",
+		Host:            host,
+		Port:            &p,
+		Params:          params,
+	}
+}
+
+func TestRequestSourceWithoutTrustedProxies(t *testing.T) {
+	req := NewRequest(
+		"",
+		INVITE,
+		&SipUri{FHost: "example.com"},
+		"SIP/2.0",
+		[]Header{ViaHeader{testViaHop("10.0.0.1", 5060, "203.0.113.9")}},
+		"",
+		log.Fields{},
+	)
+
+	if got, want := req.Source(), "203.0.113.9:5060"; got != want {
+		t.Fatalf("Source() = %q, want %q", got, want)
+	}
+}
+
+func TestRequestSourceWalksPastTrustedProxies(t *testing.T) {
+	_, proxyCIDR, err := net.ParseCIDR("10.0.0.1/32")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	transport := NewTransport()
+	transport.SetTrustedProxies([]net.IPNet{*proxyCIDR})
+
+	req := NewRequest(
+		"",
+		INVITE,
+		&SipUri{FHost: "example.com"},
+		"SIP/2.0",
+		[]Header{
+			ViaHeader{testViaHop("10.0.0.1", 5060, "")},
+			ViaHeader{testViaHop("192.0.2.10", 5061, "")},
+		},
+		"",
+		log.Fields{},
+		transport,
+	)
+
+	if got, want := req.Source(), "192.0.2.10:5061"; got != want {
+		t.Fatalf("Source() = %q, want %q", got, want)
+	}
+}
+
+func TestRequestSourceUsesTopHopByDefault(t *testing.T) {
+	// A request built with no Transport (the zero value used by most
+	// existing callers) must keep trusting only the top hop, exactly as
+	// before trusted proxies existed.
+	_, proxyCIDR, err := net.ParseCIDR("192.0.2.10/32")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := NewRequest(
+		"",
+		INVITE,
+		&SipUri{FHost: "example.com"},
+		"SIP/2.0",
+		[]Header{
+			ViaHeader{testViaHop("192.0.2.10", 5061, "")},
+			ViaHeader{testViaHop("198.51.100.7", 5062, "")},
+		},
+		"",
+		log.Fields{},
+	)
+
+	_ = proxyCIDR
+	if got, want := req.Source(), "192.0.2.10:5061"; got != want {
+		t.Fatalf("Source() = %q, want %q", got, want)
+	}
+}
+
+func TestNewPrackRequestUsesDialogCSeqNotResponseCSeq(t *testing.T) {
+	dialog := testDialog("call-prack")
+	dialog.LocalSeqNo = 5
+
+	provisional := newFakeResponse(
+		183, "Session Progress",
+		[]Header{
+			&CSeq{SeqNo: 1, MethodName: INVITE},
+			RSeqHeader(42),
+		},
+		log.Fields{},
+	)
+
+	prack := NewPrackRequest("", dialog, provisional, log.Fields{})
+
+	if prack.Method() != PRACK {
+		t.Fatalf("Method() = %v, want PRACK", prack.Method())
+	}
+
+	cseq, ok := prack.CSeq()
+	if !ok {
+		t.Fatal("expected a CSeq header")
+	}
+	// The bug this regression test guards against: CSeq used to be derived
+	// from the acknowledged response (ackedCSeq.SeqNo + 1 = 2), which
+	// collides across multiple reliable provisional responses to the same
+	// INVITE. It must instead come from the dialog's own next local CSeq.
+	if cseq.SeqNo != 6 || cseq.MethodName != PRACK {
+		t.Fatalf("CSeq = %+v, want {SeqNo:6 MethodName:PRACK}", cseq)
+	}
+	if dialog.LocalSeqNo != 6 {
+		t.Fatalf("dialog.LocalSeqNo = %d, want 6", dialog.LocalSeqNo)
+	}
+
+	rackHdrs := prack.GetHeaders("RAck")
+	if len(rackHdrs) != 1 {
+		t.Fatalf("len(RAck headers) = %d, want 1", len(rackHdrs))
+	}
+	rack, ok := rackHdrs[0].(*RAckHeader)
+	if !ok {
+		t.Fatalf("RAck header has type %T, want *RAckHeader", rackHdrs[0])
+	}
+	if rack.RSeq != 42 || rack.CSeq != 1 || rack.MethodName != INVITE {
+		t.Fatalf("RAck = %+v, want {RSeq:42 CSeq:1 MethodName:INVITE}", rack)
+	}
+}
+
+func TestNewNon2xxAckRequestKeepsInviteViaAndRoute(t *testing.T) {
+	inviteRequest := NewRequest(
+		"",
+		INVITE,
+		&SipUri{FHost: "example.com"},
+		"SIP/2.0",
+		[]Header{
+			ViaHeader{testViaHop("192.0.2.1", 5060, "")},
+			&RouteHeader{Addresses: []Uri{&SipUri{FHost: "proxy.example.com"}}},
+			CallID("call-ack"),
+			&CSeq{SeqNo: 1, MethodName: INVITE},
+		},
+		"",
+		log.Fields{},
+	)
+
+	inviteResponse := newFakeResponse(
+		487, "Request Terminated",
+		[]Header{&ToHeader{Address: &SipUri{FHost: "bob.example.com"}}},
+		log.Fields{},
+	)
+
+	ack := NewNon2xxAckRequest("", inviteRequest, inviteResponse, log.Fields{})
+
+	if ack.Method() != ACK {
+		t.Fatalf("Method() = %v, want ACK", ack.Method())
+	}
+
+	inviteHop, _ := inviteRequest.ViaHop()
+	ackHop, ok := ack.ViaHop()
+	if !ok {
+		t.Fatal("expected ACK to carry a Via header")
+	}
+	// The non-2xx ACK belongs to the same transaction as the INVITE, so it
+	// must reuse the INVITE's own Via (and thus branch) outright, unlike
+	// the 2xx ACK path, which generates a fresh branch for a new Tx.
+	if ackHop.Host != inviteHop.Host || *ackHop.Port != *inviteHop.Port {
+		t.Fatalf("ACK Via = %+v, want the INVITE's own Via %+v", ackHop, inviteHop)
+	}
+
+	if len(ack.GetHeaders("Route")) != 1 {
+		t.Fatal("expected the INVITE's Route header to carry over unchanged")
+	}
+
+	cseq, ok := ack.CSeq()
+	if !ok {
+		t.Fatal("expected a CSeq header")
+	}
+	if cseq.SeqNo != 1 || cseq.MethodName != ACK {
+		t.Fatalf("CSeq = %+v, want {SeqNo:1 MethodName:ACK}", cseq)
+	}
+}
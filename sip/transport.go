@@ -0,0 +1,129 @@
+package sip
+
+import (
+	"net"
+	"sync"
+)
+
+// Transport bundles the per-instance knobs that request building and
+// Source() resolution consult, in place of process-wide globals: the CIDRs
+// of trusted proxies in front of this transport (SetTrustedProxies) and the
+// MessageLogger used for CDR/audit logging of locally-generated messages
+// (SetMessageLogger). Each *Transport holds its own settings, so two
+// instances with different trust zones or logging policies - or tests
+// exercising both concurrently - can coexist.
+type Transport struct {
+	mu             sync.RWMutex
+	trustedProxies []net.IPNet
+	messageLogger  MessageLogger
+}
+
+// NewTransport builds a Transport with no trusted proxies and no
+// MessageLogger configured; Source() and message logging behave exactly as
+// they did before either feature existed until configured otherwise.
+func NewTransport() *Transport {
+	return &Transport{}
+}
+
+// SetTrustedProxies configures the CIDR ranges this transport treats as
+// trusted SIP proxies/SBCs sitting in front of it (e.g. a Kamailio/OpenSIPS
+// edge). Requests built through this transport resolve Source() by walking
+// past any Via hop whose host falls inside one of these ranges, rather than
+// trusting only the top hop. Passing nil restores top-hop-only behavior.
+func (t *Transport) SetTrustedProxies(proxies []net.IPNet) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.trustedProxies = proxies
+}
+
+// TrustedProxies returns the CIDRs currently configured on t. A nil
+// receiver (no transport given to a request builder) reports none.
+func (t *Transport) TrustedProxies() []net.IPNet {
+	if t == nil {
+		return nil
+	}
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	return t.trustedProxies
+}
+
+// SetMessageLogger configures the MessageLogger this transport appends
+// every locally-generated message to for CDR/audit purposes. This method is
+// the only way a logger is attached; no other option in this package sets
+// it. Pass nil to disable logging.
+func (t *Transport) SetMessageLogger(logger MessageLogger) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.messageLogger = logger
+}
+
+// MessageLogger returns the logger currently configured on t. A nil
+// receiver (no transport given to a request builder) reports none.
+func (t *Transport) MessageLogger() MessageLogger {
+	if t == nil {
+		return nil
+	}
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	return t.messageLogger
+}
+
+// logMessage is the append hook every request builder in this package
+// calls with Outbound once it has a fully-formed Request. The response
+// side (an inbound Request's replies, e.g. a future NewResponse) should
+// call this same method with the appropriate Direction once this package
+// gains response builders - none exist in this tree yet, so only the
+// request side is wired up today.
+func (t *Transport) logMessage(msg Message, dir Direction) {
+	logger := t.MessageLogger()
+	if logger == nil {
+		return
+	}
+
+	_ = logger.Append(msg, dir)
+}
+
+// resolveTransport picks the transport a builder should use out of its
+// trailing variadic parameter: none given (the common case for ad hoc
+// message construction) resolves to a nil *Transport, whose methods are
+// all nil-receiver-safe no-ops.
+func resolveTransport(transports []*Transport) *Transport {
+	if len(transports) == 0 {
+		return nil
+	}
+
+	return transports[0]
+}
+
+func isTrustedProxyHost(host string, proxies []net.IPNet) bool {
+	if len(proxies) == 0 {
+		return false
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+
+	for _, n := range proxies {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}